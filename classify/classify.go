@@ -0,0 +1,85 @@
+// Package classify scores a sample of text against a set of labeled
+// scripts or languages and reports which ones it resembles, replacing the
+// single hard-coded Chinese runemap detect-chinese used to carry.
+package classify
+
+import (
+  "bufio"
+  "io"
+  "math/rand"
+  "sort"
+)
+
+// reservoirSize is N for the Algorithm R reservoir used to bound how much
+// of a large sample is inspected.
+const reservoirSize = 500
+
+// Scorer reports whether a rune is characteristic of it, and the label to
+// report matches under. *Profile and the built-in script classifiers
+// returned by BuiltinScripts both implement it.
+type Scorer interface {
+  Label() string
+  Contains(r rune) bool
+}
+
+// Match is one Scorer whose hit ratio cleared a Classifier's threshold.
+type Match struct {
+  Label string  `json:"label"`
+  Ratio float64 `json:"ratio"`
+}
+
+// Classifier scores a sample against a fixed set of Scorers.
+type Classifier struct {
+  Scorers   []Scorer
+  Threshold float64
+}
+
+// NewClassifier builds a Classifier that reports any Scorer whose hit
+// ratio exceeds threshold.
+func NewClassifier(scorers []Scorer, threshold float64) *Classifier {
+  return &Classifier{Scorers: scorers, Threshold: threshold}
+}
+
+// Classify samples up to reservoirSize runes from body via Algorithm R
+// (for the i-th rune, it replaces a random slot with probability
+// reservoirSize/i) and returns every Scorer whose hit ratio exceeds
+// c.Threshold, strongest match first. It returns nil if body held no
+// runes.
+func (c *Classifier) Classify(body io.Reader) []Match {
+  reservoir := make([]rune, 0, reservoirSize)
+  br := bufio.NewReader(body)
+  var seen int
+  for {
+    r, _, err := br.ReadRune()
+    if err != nil {
+      break
+    }
+    seen++
+    if len(reservoir) < reservoirSize {
+      reservoir = append(reservoir, r)
+    } else if j := rand.Intn(seen); j < reservoirSize {
+      reservoir[j] = r
+    }
+  }
+  if len(reservoir) == 0 {
+    return nil
+  }
+
+  matches := make([]Match, 0, len(c.Scorers))
+  for _, s := range c.Scorers {
+    var hits float64
+    for _, r := range reservoir {
+      if s.Contains(r) {
+        hits++
+      }
+    }
+    ratio := hits / float64(len(reservoir))
+    if ratio > c.Threshold {
+      matches = append(matches, Match{Label: s.Label(), Ratio: ratio})
+    }
+  }
+  sort.Slice(matches, func(i, j int) bool { return matches[i].Ratio > matches[j].Ratio })
+  return matches
+}
+
+// END
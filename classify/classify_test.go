@@ -0,0 +1,69 @@
+package classify
+
+import (
+  "strings"
+  "testing"
+)
+
+type constScorer struct {
+  label string
+  in    map[rune]bool
+}
+
+func (s *constScorer) Label() string        { return s.label }
+func (s *constScorer) Contains(r rune) bool { return s.in[r] }
+
+func TestClassifyReportsAboveThreshold(t *testing.T) {
+  han := &constScorer{label: "han", in: map[rune]bool{'漢': true, '字': true}}
+  c := NewClassifier([]Scorer{han}, 0.4)
+
+  matches := c.Classify(strings.NewReader("漢字ab"))
+  if len(matches) != 1 {
+    t.Fatalf("Classify() = %v, want exactly one match", matches)
+  }
+  if matches[0].Label != "han" {
+    t.Errorf("matches[0].Label = %q, want han", matches[0].Label)
+  }
+  if matches[0].Ratio != 0.5 {
+    t.Errorf("matches[0].Ratio = %v, want 0.5", matches[0].Ratio)
+  }
+}
+
+func TestClassifyBelowThresholdExcluded(t *testing.T) {
+  han := &constScorer{label: "han", in: map[rune]bool{'漢': true}}
+  c := NewClassifier([]Scorer{han}, 0.5)
+
+  matches := c.Classify(strings.NewReader("漢aaaa"))
+  if len(matches) != 0 {
+    t.Errorf("Classify() = %v, want no matches below threshold", matches)
+  }
+}
+
+func TestClassifyEmptyBody(t *testing.T) {
+  c := NewClassifier(BuiltinScripts(), 0.1)
+  if matches := c.Classify(strings.NewReader("")); matches != nil {
+    t.Errorf("Classify(empty) = %v, want nil", matches)
+  }
+}
+
+func TestClassifySortsStrongestFirst(t *testing.T) {
+  strong := &constScorer{label: "strong", in: map[rune]bool{'a': true, 'b': true}}
+  weak := &constScorer{label: "weak", in: map[rune]bool{'a': true}}
+  c := NewClassifier([]Scorer{weak, strong}, 0)
+
+  matches := c.Classify(strings.NewReader("aabb"))
+  if len(matches) != 2 {
+    t.Fatalf("Classify() = %v, want two matches", matches)
+  }
+  if matches[0].Label != "strong" {
+    t.Errorf("matches[0].Label = %q, want strong (higher ratio)", matches[0].Label)
+  }
+}
+
+func TestBuiltinScriptsClassifyHan(t *testing.T) {
+  c := NewClassifier(BuiltinScripts(), 0.5)
+  matches := c.Classify(strings.NewReader("你好世界"))
+  if len(matches) != 1 || matches[0].Label != "han" {
+    t.Errorf("Classify(Chinese text) = %v, want single han match", matches)
+  }
+}
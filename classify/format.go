@@ -0,0 +1,30 @@
+package classify
+
+import (
+  "encoding/json"
+  "fmt"
+)
+
+// FormatLine renders the result of classifying the record named id: a
+// plain "id\tlabel\tratio" line when exactly one profile matched, a JSON
+// line carrying every match when several did, and "" when none did.
+func FormatLine(id string, matches []Match) string {
+  switch len(matches) {
+  case 0:
+    return ""
+  case 1:
+    return fmt.Sprintf("%s\t%s\t%.4f", id, matches[0].Label, matches[0].Ratio)
+  default:
+    out := struct {
+      ID      string  `json:"id"`
+      Matches []Match `json:"matches"`
+    }{ID: id, Matches: matches}
+    b, err := json.Marshal(out)
+    if err != nil {
+      panic(err)
+    }
+    return string(b)
+  }
+}
+
+// END
@@ -0,0 +1,38 @@
+package classify
+
+import (
+  "encoding/json"
+  "testing"
+)
+
+func TestFormatLineNoMatches(t *testing.T) {
+  if got := FormatLine("rec-1", nil); got != "" {
+    t.Errorf("FormatLine(nil) = %q, want \"\"", got)
+  }
+}
+
+func TestFormatLineSingleMatch(t *testing.T) {
+  got := FormatLine("rec-1", []Match{{Label: "han", Ratio: 0.75}})
+  want := "rec-1\than\t0.7500"
+  if got != want {
+    t.Errorf("FormatLine() = %q, want %q", got, want)
+  }
+}
+
+func TestFormatLineMultipleMatches(t *testing.T) {
+  matches := []Match{{Label: "han", Ratio: 0.8}, {Label: "hiragana", Ratio: 0.6}}
+  got := FormatLine("rec-1", matches)
+
+  var decoded struct {
+    ID      string  `json:"id"`
+    Matches []Match `json:"matches"`
+  }
+  if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+    t.Fatalf("FormatLine() produced invalid JSON: %v (%q)", err, got)
+  }
+  if decoded.ID != "rec-1" || len(decoded.Matches) != 2 {
+    t.Errorf("decoded = %+v, want ID=rec-1 and 2 matches", decoded)
+  }
+}
+
+// END
@@ -0,0 +1,73 @@
+package classify
+
+import (
+  "bufio"
+  "os"
+  "path/filepath"
+  "strings"
+)
+
+// Profile is a labeled set of runes loaded from a data file, one rune per
+// line (only the first rune of each line is read, matching the layout
+// detect-chinese/ordered_characters used).
+type Profile struct {
+  label string
+  runes map[rune]bool
+}
+
+// Label returns the profile's name, taken from its source filename.
+func (p *Profile) Label() string { return p.label }
+
+// Contains reports whether r belongs to the profile's rune set.
+func (p *Profile) Contains(r rune) bool { return p.runes[r] }
+
+// LoadProfile reads a single profile from path, labeling it after path's
+// base filename with any extension stripped.
+func LoadProfile(path string) (*Profile, error) {
+  in, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  defer in.Close()
+
+  p := &Profile{
+    label: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+    runes: make(map[rune]bool),
+  }
+  scanner := bufio.NewScanner(in)
+  for scanner.Scan() {
+    line := strings.TrimSpace(scanner.Text())
+    for _, r := range line {
+      p.runes[r] = true
+      // Only the first rune of each line is meaningful.
+      break
+    }
+  }
+  if err := scanner.Err(); err != nil {
+    return nil, err
+  }
+  return p, nil
+}
+
+// LoadProfiles loads every regular file in dir as a Profile, one file per
+// language.
+func LoadProfiles(dir string) ([]*Profile, error) {
+  entries, err := os.ReadDir(dir)
+  if err != nil {
+    return nil, err
+  }
+  profiles := make([]*Profile, 0, len(entries))
+  for _, e := range entries {
+    if e.IsDir() {
+      continue
+    }
+    p, err := LoadProfile(filepath.Join(dir, e.Name()))
+    if err != nil {
+      return nil, err
+    }
+    profiles = append(profiles, p)
+  }
+  return profiles, nil
+}
+
+// END
@@ -0,0 +1,50 @@
+package classify
+
+import (
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func TestLoadProfile(t *testing.T) {
+  dir := t.TempDir()
+  path := filepath.Join(dir, "klingon.txt")
+  if err := os.WriteFile(path, []byte("a extra\nb more\n\nc\n"), 0o644); err != nil {
+    t.Fatalf("WriteFile: %v", err)
+  }
+
+  p, err := LoadProfile(path)
+  if err != nil {
+    t.Fatalf("LoadProfile: %v", err)
+  }
+  if p.Label() != "klingon" {
+    t.Errorf("Label() = %q, want klingon", p.Label())
+  }
+  for _, r := range []rune{'a', 'b', 'c'} {
+    if !p.Contains(r) {
+      t.Errorf("Contains(%q) = false, want true", r)
+    }
+  }
+  if p.Contains('x') {
+    t.Error("Contains('x') = true, want false")
+  }
+}
+
+func TestLoadProfiles(t *testing.T) {
+  dir := t.TempDir()
+  for _, name := range []string{"one.txt", "two.txt"} {
+    if err := os.WriteFile(filepath.Join(dir, name), []byte("a\n"), 0o644); err != nil {
+      t.Fatalf("WriteFile: %v", err)
+    }
+  }
+
+  profiles, err := LoadProfiles(dir)
+  if err != nil {
+    t.Fatalf("LoadProfiles: %v", err)
+  }
+  if len(profiles) != 2 {
+    t.Errorf("LoadProfiles() returned %d profiles, want 2", len(profiles))
+  }
+}
+
+// END
@@ -0,0 +1,31 @@
+package classify
+
+import "unicode"
+
+// scriptProfile adapts one of the unicode package's range tables to
+// Scorer, so a common script can be classified with no data file.
+type scriptProfile struct {
+  label string
+  table *unicode.RangeTable
+}
+
+func (s *scriptProfile) Label() string { return s.label }
+
+func (s *scriptProfile) Contains(r rune) bool { return unicode.Is(s.table, r) }
+
+// BuiltinScripts returns classifiers for scripts common enough to ship
+// with no data files: Han, Hiragana, Katakana, Hangul, Cyrillic, Arabic,
+// and Devanagari.
+func BuiltinScripts() []Scorer {
+  return []Scorer{
+    &scriptProfile{"han", unicode.Han},
+    &scriptProfile{"hiragana", unicode.Hiragana},
+    &scriptProfile{"katakana", unicode.Katakana},
+    &scriptProfile{"hangul", unicode.Hangul},
+    &scriptProfile{"cyrillic", unicode.Cyrillic},
+    &scriptProfile{"arabic", unicode.Arabic},
+    &scriptProfile{"devanagari", unicode.Devanagari},
+  }
+}
+
+// END
@@ -1,26 +1,34 @@
 package main
 
-import "fmt"
-import "os"
-import "io"
-import "bufio"
-import "strings"
-import "math/rand"
-import "sort"
-import "flag"
-import "log"
-import "runtime/pprof"
-import "unicode/utf8"
-
-var chinese_chars_fn = "detect-chinese/ordered_characters"
+import (
+  "bufio"
+  "flag"
+  "fmt"
+  "io"
+  "log"
+  "os"
+  "path/filepath"
+  "runtime"
+  "runtime/pprof"
+  "strings"
+  "sync"
+
+  "github.com/kbullaughey/warc-tools/classify"
+)
+
+// languagesDir, rooted at $WARC_TOOLS_DIR, holds one rune-list file per
+// language in the layout ordered_characters used for Chinese alone.
+var languagesDir = "detect-chinese/languages"
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
 var memprofile = flag.String("memprofile", "", "write memory profile to this file")
 
-//type record []string
+const threshold = 0.35
+
+var classifier *classify.Classifier
+
 type warcRecord struct {
   lines []string
-  id string
-  body string
+  id    string
 }
 
 func interpret(r []string) *warcRecord {
@@ -50,150 +58,102 @@ func interpret(r []string) *warcRecord {
   // Discard blank records
   if len(body) == 0 { return nil }
   if warc.id == "" { panic("Record missing ID") }
-  warc.body = strings.Join(body, " ")
+  warc.lines = body
   return &warc
 }
 
-func process(r []string, ch chan string) {
-  warc := interpret(r)
-  threshold := 0.35
-
-  // Nil records can be safely skipped
-  if warc == nil {
-    ch <- ""
-    return
+// bodyReader returns an io.Reader over the record's body text, for
+// process to decode rune-by-rune without first collecting the whole body
+// into a slice of runes. It reads directly from w.lines, interleaving the
+// same single-space separator strings.Join would have used, rather than
+// joining them into one string up front.
+func (w *warcRecord) bodyReader() io.Reader {
+  if len(w.lines) == 0 {
+    return strings.NewReader("")
   }
-
-  var n int = 500
-  var c float64
-  if len(warc.body) <= n {
-    n = 0
-    // Since we have a short string, we look at all characters
-    for _,ru := range warc.body {
-      _, ok := chinese_chars[ru]
-      if ok { c += 1.0 }
-      n++
-    }
-    ratio := c/float64(n)
-    if ratio > threshold {
-      ch <- warc.id
-    } else {
-      ch <- ""
+  readers := make([]io.Reader, 0, 2*len(w.lines)-1)
+  for i, line := range w.lines {
+    if i > 0 {
+      readers = append(readers, strings.NewReader(" "))
     }
-    return
+    readers = append(readers, strings.NewReader(line))
   }
+  return io.MultiReader(readers...)
+}
 
-  // Generate random indices uniformly on the number of bytes in the string.
-  sample_indices := make([]int, n)
-  for i := 0; i < n; i++ {
-    sample_indices[i] = rand.Int() % len(warc.body)
-  }
-  // Sort the indices so we can find them in order as we go through the string
-  sort.Ints(sample_indices)
-
-  // Go through and count how many samples are Chinese characters
-  var j int // This will keep track of our position in sample_indices
-  var m int // Keep track only of unique characters tested.
-  for k,ru := range warc.body {
-    rune_len := utf8.RuneLen(ru)
-    if sample_indices[j] < k + rune_len {
-      _, ok := chinese_chars[ru]
-      if ok { c += 1.0 }
-      m++
-      // Increment, skipping duplicates
-      for j < n && sample_indices[j] < (k + rune_len) { j++ }
-      // If we've found all our samples, break
-      if j == n { break }
-    }
-  }
-  if j != n { panic(fmt.Sprintf("Only found %d of %d samples", j, n)) }
-  ratio := c/float64(n)
-  if ratio > threshold {
-//    ch <- strings.Join(r, "\n")
-    ch <- warc.id
-  } else {
-    ch <- ""
-  }
-  return
+type job struct {
+  id   string
+  body io.Reader
 }
 
-type runemap map[rune]bool
-var chinese_chars runemap
+// process classifies body against classifier and reports a formatted
+// result line for id on ch, or "" if nothing matched.
+func process(id string, body io.Reader, ch chan<- string) {
+  matches := classifier.Classify(body)
+  ch <- classify.FormatLine(id, matches)
+}
 
-func learnChinese() {
-  chinese_chars = make(runemap)
-  root := os.Getenv("WARC_TOOLS_DIR")
-  if root == "" {
-    log.Fatal("Must have WARC_TOOLS_DIR set")
-  }
-  chinese_chars_path := fmt.Sprintf("%s/%s", root, chinese_chars_fn)
-  in, err := os.Open(chinese_chars_path)
-  if err != nil { panic(err) }
-  // close file on exit and check for its returned error
-  defer func() {
-    if err := in.Close(); err != nil {
-      panic(err)
-    }
-  }()
-  reader := bufio.NewReader(in)
-  for {
-    s, err := reader.ReadString('\n')
-    if err != nil {
-      if err == io.EOF {
-        break
-      }
-      panic(err)
+// loadClassifier builds a Classifier from the built-in scripts, plus any
+// per-language profiles found under $WARC_TOOLS_DIR/<languagesDir>.
+func loadClassifier() *classify.Classifier {
+  scorers := classify.BuiltinScripts()
+  if root := os.Getenv("WARC_TOOLS_DIR"); root != "" {
+    dir := filepath.Join(root, languagesDir)
+    profiles, err := classify.LoadProfiles(dir)
+    if err != nil && !os.IsNotExist(err) {
+      log.Fatal(err)
     }
-    s = strings.TrimSpace(s)
-    for _, ru := range s {
-      chinese_chars[ru] = true
-      // I only care about the first rune
-      break
+    for _, p := range profiles {
+      scorers = append(scorers, p)
     }
   }
+  return classify.NewClassifier(scorers, threshold)
 }
 
-func printResults(ch chan string, count_ch chan int) {
-  // We should receive one result per goroutine
-  var expecting int
-  var received_count int = 1
-  for {
-    select {
-      case response := <-ch:
-        if response != "" {
-          fmt.Println(response)
-        }
-        received_count += 1
-      case expecting = <-count_ch:
-    }
-    if expecting > 0 && received_count == expecting {
-      break
+func printResults(ch <-chan string) {
+  for line := range ch {
+    if line != "" {
+      fmt.Println(line)
     }
   }
-  // Indicate we're done.
-  count_ch <- 0
 }
 
-func launch() {
-  /*  Channel to tell printResults (after it starts) how many messages it should
-      receive. We only know this after we've scheduled all the goroutines */
-  count_ch := make(chan int)
+// dispatch interprets rec and, unless it's discardable, hands it to the
+// worker pool via jobs.
+func dispatch(jobs chan<- job, rec []string) {
+  warc := interpret(rec)
+  if warc == nil {
+    return
+  }
+  jobs <- job{id: warc.id, body: warc.bodyReader()}
+}
 
-  // Channel for sending strings to printResults.
+func readWarc() {
+  // jobs feeds a bounded pool of workers, capped at runtime.NumCPU(),
+  // instead of launching one goroutine per record.
+  jobs := make(chan job, 100)
   ch := make(chan string, 100)
 
-  go printResults(ch, count_ch)
-  go readWarc(ch, count_ch)
+  var workers sync.WaitGroup
+  for i := 0; i < runtime.NumCPU(); i++ {
+    workers.Add(1)
+    go func() {
+      defer workers.Done()
+      for j := range jobs {
+        process(j.id, j.body, ch)
+      }
+    }()
+  }
 
-  // Wait for printing to finish
-  <-count_ch
-}
+  printingDone := make(chan struct{})
+  go func() {
+    printResults(ch)
+    close(printingDone)
+  }()
 
-func readWarc(ch chan string, count_ch chan int) {
   reader := bufio.NewReader(os.Stdin)
   // start with an empty record.
   var rec []string
-  var responses_to_expect int = 0
   for {
     s, err := reader.ReadString('\n')
     if err != nil {
@@ -204,18 +164,25 @@ func readWarc(ch chan string, count_ch chan int) {
     }
     s = strings.TrimSpace(s)
     if s == "WARC/1.0" {
-      /* Process the record. If the fraction of Chinese is high enough, then
-         print it out. Reset the record afterwards. */
-      go process(rec, ch)
-      responses_to_expect++
+      /* Dispatch the record accumulated so far to the worker pool. If it
+         classifies above threshold its "id\tlabel\tratio" line (or a JSON
+         line, when several labels match) will be printed. Reset the record
+         afterwards. */
+      dispatch(jobs, rec)
       rec = make([]string, 0, 20)
     }
     rec = append(rec, s)
   }
-
-  // tell printResults how many messages it should receive
-  count_ch <- responses_to_expect
-  return
+  // Dispatch the final record, which has no following "WARC/1.0" line to
+  // trigger it.
+  dispatch(jobs, rec)
+
+  // No more records are coming; let the workers drain jobs, then close ch
+  // so printResults knows it has seen every result.
+  close(jobs)
+  workers.Wait()
+  close(ch)
+  <-printingDone
 }
 
 func main() {
@@ -226,8 +193,8 @@ func main() {
     pprof.StartCPUProfile(f)
     defer pprof.StopCPUProfile()
   }
-  learnChinese()
-  launch()
+  classifier = loadClassifier()
+  readWarc()
   if *memprofile != "" {
     f, err := os.Create(*memprofile)
     if err != nil { log.Fatal(err) }
@@ -238,5 +205,3 @@ func main() {
 }
 
 // END
-
-
@@ -0,0 +1,42 @@
+package main
+
+import (
+  "io"
+  "testing"
+)
+
+func TestBodyReaderJoinsLinesWithSpaces(t *testing.T) {
+  w := &warcRecord{lines: []string{"one", "two", "three"}}
+  got, err := io.ReadAll(w.bodyReader())
+  if err != nil {
+    t.Fatalf("ReadAll: %v", err)
+  }
+  want := "one two three"
+  if string(got) != want {
+    t.Errorf("bodyReader() read %q, want %q", got, want)
+  }
+}
+
+func TestBodyReaderSingleLine(t *testing.T) {
+  w := &warcRecord{lines: []string{"solo"}}
+  got, err := io.ReadAll(w.bodyReader())
+  if err != nil {
+    t.Fatalf("ReadAll: %v", err)
+  }
+  if string(got) != "solo" {
+    t.Errorf("bodyReader() read %q, want %q", got, "solo")
+  }
+}
+
+func TestBodyReaderEmptyLines(t *testing.T) {
+  w := &warcRecord{lines: nil}
+  got, err := io.ReadAll(w.bodyReader())
+  if err != nil {
+    t.Fatalf("ReadAll: %v", err)
+  }
+  if string(got) != "" {
+    t.Errorf("bodyReader() read %q, want empty", got)
+  }
+}
+
+// END
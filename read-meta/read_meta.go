@@ -2,16 +2,26 @@ package main
 
 import (
   "bufio"
+  "bytes"
   "compress/gzip"
   "encoding/json"
+  "flag"
   "fmt"
   "log"
   "io"
   "os"
+  "path/filepath"
   "strings"
-  "strconv"
+
+  "github.com/kbullaughey/warc-tools/sniff"
+  "github.com/kbullaughey/warc-tools/warc"
 )
 
+var extract = flag.Bool("extract", false, "emit raw response record bodies instead of filename/offset pairs")
+var warcDir = flag.String("warc-dir", "data", "directory containing the .warc.gz files the WAT refers to")
+var watFn = flag.String("wat", "data/CC-MAIN-20130516092621-00000-ip-10-60-113-184.ec2.internal.warc.wat.gz", "path to the compressed WARC metadata (WAT) file")
+var format = flag.String("format", "", "if set, keep only response records whose payload sniffs as this format (e.g. html), regardless of declared Content-Type")
+
 type GzipMetaData struct {
   FooterLength int `json:"Footer-Length,string"`
   DeflateLength int `json:"Deflate-Length,string"`
@@ -65,83 +75,18 @@ type WarcMeta struct {
   Container WarcContainer
 }
 
-type record struct {
-  length int
-  refersTo string
-  header []string
-  warcType string
-  data []byte
-}
-
-// Returns nil on EOF
-func nextRecord(reader *bufio.Reader) (*record, error) {
-  rec := record{}
-  var failSafe int = 100
-  var line string
-  var err error
-
-  /* We should always enter this function at the beginning of a record (possibly
-     skipping blank lines */
-  for {
-    line, err = reader.ReadString('\n')
-    if err != nil { return nil, err }
-    line = strings.TrimSpace(line)
-    if line == "WARC/1.0" {
-      break
-    } else if line == "" {
-      continue
-    } else {
-      panic("Malformed first line")
-    }
-  }
-  rec.header = append(rec.header, line)
-
-  // Get the rest of the header
-  for {
-    line, err = reader.ReadString('\n')
-    if err != nil {
-      if err == io.EOF { break }
-      return nil, err
-    }
-    line = strings.TrimSpace(line)
-    if line == "" { break }
-    if strings.HasPrefix(line, "WARC-Type: ") {
-      rec.warcType = line[11:]
-    } else if strings.HasPrefix(line, "Content-Length: ") {
-      rec.length, err = strconv.Atoi(line[16:])
-      if err != nil { return nil, err }
-    } else if strings.HasPrefix(line, "WARC-Refers-To: ") {
-      rec.refersTo = line[16:]
-    }
-    rec.header = append(rec.header, line)
-    failSafe--
-    if failSafe == 0 { panic("Hit failsafe when reading header") }
-  }
-
-  // No read in the data
-  if rec.length == 0 { panic("No record length") }
-  rec.data = make([]byte, rec.length)
-  var bytes int
-  for bytes < rec.length {
-    n, err := reader.Read(rec.data[bytes:])
-    if err != nil { return nil, err }
-    bytes += n
-  }
-  if bytes != rec.length {
-    panic(fmt.Sprintf("Only read %d bytes, expecting %d\n", bytes, rec.length))
-  }
-
-  return &rec, err
-}
-
-/* Search reader for WARC meta records that refer to the records given by ids */
+/* Search reader for WARC meta records that refer to the records given by
+   ids. When *extract is set, the matched response records are looked up
+   in their source .warc.gz (by filename/offset/deflate-length) and their
+   raw bodies are written to stdout; otherwise the filename/offset pairs
+   are printed, one filename per group of records. */
 func readMeta(reader *bufio.Reader, ids *recordSet) {
-  var rec *record
+  var rec *warc.Record
   var err error
   var meta WarcMeta
   var filename string
   for {
-    rec, err = nextRecord(reader)
+    rec, err = warc.ReadRecord(reader, warc.OnMismatchSkip)
     if err != nil {
       if err == io.EOF {
         break
@@ -149,33 +94,75 @@ func readMeta(reader *bufio.Reader, ids *recordSet) {
       log.Fatal(err)
     }
     // Skip non-metadata records
-    if rec.warcType != "metadata" { continue }
+    if rec.Type != "metadata" { continue }
     // Skip records that are not the ones for which we're looking
-    if rec.refersTo == "" { panic("No refersTo") }
-    if !((*ids)[rec.refersTo]) { continue }
-    if err := json.Unmarshal(rec.data, &meta); err != nil {
+    if rec.RefersTo == "" { panic("No refersTo") }
+    if !((*ids)[rec.RefersTo]) { continue }
+    if err := json.Unmarshal(rec.Data, &meta); err != nil {
       log.Fatal(err)
     }
     if meta.Envelope.HeaderMetaData.Type == "response" {
       status := meta.Envelope.PayloadMetaData.ResponseMeta.ResponseInfo.Status
       if status != 200 { continue }
-      contentType := meta.Envelope.PayloadMetaData.ResponseMeta.Headers.ContentType
-      if strings.Contains(contentType, "text/html") {
-        /* Each time we encounter a new filename we output it. Blank lines between
-           records */
-        if filename != meta.Container.Filename {
-          if filename != "" {
-            fmt.Println("")
-          }
-          filename = meta.Container.Filename
-          fmt.Println(filename)
+
+      if *format != "" {
+        if !sniffsAs(meta.Container, *format) { continue }
+      } else {
+        contentType := meta.Envelope.PayloadMetaData.ResponseMeta.Headers.ContentType
+        if !strings.Contains(contentType, "text/html") { continue }
+      }
+
+      if *extract {
+        extractResponse(meta.Container)
+        continue
+      }
+      /* Each time we encounter a new filename we output it. Blank lines between
+         records */
+      if filename != meta.Container.Filename {
+        if filename != "" {
+          fmt.Println("")
         }
-        fmt.Println(meta.Container.Offset, meta.Container.GzipMeta.DeflateLength)
+        filename = meta.Container.Filename
+        fmt.Println(filename)
       }
+      fmt.Println(meta.Container.Offset, meta.Container.GzipMeta.DeflateLength)
     }
   }
 }
 
+// sniffsAs reports whether the response record c points to actually
+// sniffs as format, regardless of what its declared Content-Type claimed.
+func sniffsAs(c WarcContainer, format string) bool {
+  file := filepath.Join(*warcDir, c.Filename)
+  rec, err := warc.RecordAt(file, int64(c.Offset), int64(c.GzipMeta.DeflateLength), warc.OnMismatchWarn)
+  if err != nil {
+    log.Print(err)
+    return false
+  }
+  got, err := sniff.Identify(bytes.NewReader(warc.HTTPBody(rec.Data)))
+  if err != nil {
+    log.Print(err)
+    return false
+  }
+  return got == format
+}
+
+// extractResponse seeks into the .warc.gz named by c.Filename and writes
+// the raw content block of the response record it points to (everything
+// after its WARC header) to stdout. A record
+// whose digest doesn't match is logged and skipped rather than trusted,
+// since a corrupt WAT offset would otherwise hand the Chinese-detection
+// filter silent garbage.
+func extractResponse(c WarcContainer) {
+  file := filepath.Join(*warcDir, c.Filename)
+  rec, err := warc.RecordAt(file, int64(c.Offset), int64(c.GzipMeta.DeflateLength), warc.OnMismatchFail)
+  if err != nil {
+    log.Print(err)
+    return
+  }
+  os.Stdout.Write(rec.Data)
+}
+
 type recordSet map[string]bool
 
 /* Build a map of records that we'll look for in the metadata. These come
@@ -196,11 +183,11 @@ func getRecordSubset() *recordSet {
 }
 
 func main() {
+  flag.Parse()
   ids := getRecordSubset()
   fmt.Printf("Found %d ids\n", len(*ids))
   // Open the compressed WARC metadata file
-  meta_fn := "data/CC-MAIN-20130516092621-00000-ip-10-60-113-184.ec2.internal.warc.wat.gz"
-  gzMetaFile, err := os.Open(meta_fn)
+  gzMetaFile, err := os.Open(*watFn)
   if err != nil { log.Fatal(err) }
   // Close file on exit and check for its returned error
   defer func() {
@@ -0,0 +1,179 @@
+// Package sniff identifies a file format from its leading bytes using a
+// compact, PRONOM-style magic-signature table, for payloads whose
+// declared Content-Type can't be trusted.
+package sniff
+
+import (
+  "bytes"
+  _ "embed"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+  "io"
+  "sync"
+  "unicode/utf8"
+)
+
+// sniffLen is how much of a stream Identify reads before giving up.
+const sniffLen = 4096
+
+//go:embed signatures.json
+var signaturesJSON []byte
+
+// signature is one entry of the embedded table: either Hex, an exact byte
+// pattern, or Text, a case-insensitive textual marker. Offset is the
+// position the pattern must start at, or -1 to match at the start of the
+// sample, after skipping any leading whitespace/BOM. Offset2/Hex2, if
+// set, name a second byte pattern that must also match for the signature
+// to fire, e.g. webp's outer RIFF container magic at offset 0 in
+// addition to its "WEBP" marker at offset 8.
+type signature struct {
+  Format  string `json:"format"`
+  Offset  int    `json:"offset"`
+  Hex     string `json:"hex,omitempty"`
+  Text    string `json:"text,omitempty"`
+  Offset2 int    `json:"offset2,omitempty"`
+  Hex2    string `json:"hex2,omitempty"`
+
+  pattern  []byte
+  pattern2 []byte
+}
+
+var (
+  loadOnce   sync.Once
+  signatures []signature
+  loadErr    error
+)
+
+func loadSignatures() ([]signature, error) {
+  loadOnce.Do(func() {
+    var sigs []signature
+    if err := json.Unmarshal(signaturesJSON, &sigs); err != nil {
+      loadErr = fmt.Errorf("sniff: parsing signature table: %v", err)
+      return
+    }
+    for i := range sigs {
+      switch {
+      case sigs[i].Hex != "":
+        pattern, err := hex.DecodeString(sigs[i].Hex)
+        if err != nil {
+          loadErr = fmt.Errorf("sniff: signature %q has invalid hex: %v", sigs[i].Format, err)
+          return
+        }
+        sigs[i].pattern = pattern
+      case sigs[i].Text != "":
+        sigs[i].pattern = bytes.ToLower([]byte(sigs[i].Text))
+      }
+      if sigs[i].Hex2 != "" {
+        pattern2, err := hex.DecodeString(sigs[i].Hex2)
+        if err != nil {
+          loadErr = fmt.Errorf("sniff: signature %q has invalid hex2: %v", sigs[i].Format, err)
+          return
+        }
+        sigs[i].pattern2 = pattern2
+      }
+    }
+    signatures = sigs
+  })
+  return signatures, loadErr
+}
+
+// Identify reads (and so consumes) up to the first sniffLen bytes of r and
+// returns the format label of
+// the first signature in the table that matches. If nothing matches but
+// the sample still looks like readable text, it returns "text". If r is
+// empty or looks like neither, it returns "".
+func Identify(r io.Reader) (string, error) {
+  sigs, err := loadSignatures()
+  if err != nil {
+    return "", err
+  }
+
+  buf := make([]byte, sniffLen)
+  n, err := io.ReadFull(r, buf)
+  if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+    return "", err
+  }
+  sample := buf[:n]
+
+  for _, sig := range sigs {
+    pattern := sig.pattern
+    haystack := sample
+    if sig.Text != "" {
+      haystack = bytes.ToLower(sample)
+    }
+    if !matchesAt(haystack, pattern, sig.Offset) {
+      continue
+    }
+    if sig.pattern2 != nil && !matchesAt(sample, sig.pattern2, sig.Offset2) {
+      continue
+    }
+    return sig.Format, nil
+  }
+
+  if looksLikeText(sample) {
+    return "text", nil
+  }
+  return "", nil
+}
+
+// matchesAt reports whether pattern occurs in sample at offset, or at the
+// start of sample (after skipping leading whitespace/BOM) when offset is
+// negative.
+func matchesAt(sample, pattern []byte, offset int) bool {
+  if len(pattern) == 0 {
+    return false
+  }
+  if offset < 0 {
+    sample = skipBOMAndSpace(sample)
+    offset = 0
+  }
+  end := offset + len(pattern)
+  if end > len(sample) {
+    return false
+  }
+  return bytes.Equal(sample[offset:end], pattern)
+}
+
+// utf8BOM is the byte sequence a UTF-8 byte-order mark is encoded as.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// skipBOMAndSpace returns sample with any leading UTF-8 BOM and/or
+// leading whitespace removed, so offset: -1 signatures anchor to the
+// first real content byte instead of matching anywhere in the window.
+func skipBOMAndSpace(sample []byte) []byte {
+  sample = bytes.TrimPrefix(sample, utf8BOM)
+  i := 0
+  for i < len(sample) {
+    switch sample[i] {
+    case ' ', '\t', '\r', '\n':
+      i++
+      continue
+    }
+    break
+  }
+  return sample[i:]
+}
+
+// looksLikeText reports whether sample is plausibly human-readable text:
+// valid UTF-8 with no NUL bytes or other control characters besides
+// common whitespace.
+func looksLikeText(sample []byte) bool {
+  if len(sample) == 0 {
+    return false
+  }
+  if !utf8.Valid(sample) {
+    return false
+  }
+  for _, b := range sample {
+    if b < 0x09 {
+      return false
+    }
+    if b >= 0x0e && b < 0x20 {
+      return false
+    }
+  }
+  return true
+}
+
+// END
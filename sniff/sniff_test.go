@@ -0,0 +1,125 @@
+package sniff
+
+import (
+  "strings"
+  "testing"
+)
+
+func TestIdentifyMagicBytes(t *testing.T) {
+  cases := []struct {
+    name string
+    data []byte
+    want string
+  }{
+    {"png", []byte{0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0, 0}, "png"},
+    {"gzip", []byte{0x1f, 0x8b, 0, 0}, "gzip"},
+    {"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}, "zstd"},
+    {"webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "webp"},
+  }
+  for _, c := range cases {
+    t.Run(c.name, func(t *testing.T) {
+      got, err := Identify(strings.NewReader(string(c.data)))
+      if err != nil {
+        t.Fatalf("Identify: %v", err)
+      }
+      if got != c.want {
+        t.Errorf("Identify() = %q, want %q", got, c.want)
+      }
+    })
+  }
+}
+
+func TestIdentifyHTMLAtStart(t *testing.T) {
+  got, err := Identify(strings.NewReader("<html><body>hi</body></html>"))
+  if err != nil {
+    t.Fatalf("Identify: %v", err)
+  }
+  if got != "html" {
+    t.Errorf("Identify() = %q, want html", got)
+  }
+}
+
+func TestIdentifyHTMLAfterLeadingWhitespaceAndBOM(t *testing.T) {
+  got, err := Identify(strings.NewReader("\xef\xbb\xbf\n  <!doctype html><html></html>"))
+  if err != nil {
+    t.Fatalf("Identify: %v", err)
+  }
+  if got != "html" {
+    t.Errorf("Identify() = %q, want html", got)
+  }
+}
+
+func TestIdentifyDoesNotMisclassifyWEBPMarkerOutsideRIFFContainer(t *testing.T) {
+  // Regression test: the "WEBP" bytes at offset 8 alone aren't enough;
+  // without the RIFF magic at offset 0 this isn't a webp file.
+  got, err := Identify(strings.NewReader("XXXX\x00\x00\x00\x00WEBPVP8 "))
+  if err != nil {
+    t.Fatalf("Identify: %v", err)
+  }
+  if got == "webp" {
+    t.Errorf("Identify() = webp, want non-webp classification without a RIFF header")
+  }
+}
+
+func TestIdentifyDoesNotMisclassifyTextMentioningHTML(t *testing.T) {
+  // Regression test: this plain-text document merely discusses HTML
+  // elsewhere in its body, and must not be sniffed as html.
+  got, err := Identify(strings.NewReader("This article explains what <html> tags are for."))
+  if err != nil {
+    t.Fatalf("Identify: %v", err)
+  }
+  if got == "html" {
+    t.Errorf("Identify() = html, want non-html classification for plain text merely mentioning it")
+  }
+  if got != "text" {
+    t.Errorf("Identify() = %q, want text", got)
+  }
+}
+
+func TestIdentifyPlainText(t *testing.T) {
+  got, err := Identify(strings.NewReader("just some ordinary text"))
+  if err != nil {
+    t.Fatalf("Identify: %v", err)
+  }
+  if got != "text" {
+    t.Errorf("Identify() = %q, want text", got)
+  }
+}
+
+func TestIdentifyEmpty(t *testing.T) {
+  got, err := Identify(strings.NewReader(""))
+  if err != nil {
+    t.Fatalf("Identify: %v", err)
+  }
+  if got != "" {
+    t.Errorf("Identify(empty) = %q, want \"\"", got)
+  }
+}
+
+func TestMatchesAtFixedOffset(t *testing.T) {
+  sample := []byte("xxpng")
+  if !matchesAt(sample, []byte("png"), 2) {
+    t.Error("matchesAt() = false, want true for pattern at declared offset")
+  }
+  if matchesAt(sample, []byte("png"), 0) {
+    t.Error("matchesAt() = true, want false when pattern isn't at declared offset")
+  }
+}
+
+func TestSkipBOMAndSpace(t *testing.T) {
+  cases := []struct {
+    in   string
+    want string
+  }{
+    {"\xef\xbb\xbf<html>", "<html>"},
+    {"  \n\t<html>", "<html>"},
+    {"<html>", "<html>"},
+  }
+  for _, c := range cases {
+    if got := string(skipBOMAndSpace([]byte(c.in))); got != c.want {
+      t.Errorf("skipBOMAndSpace(%q) = %q, want %q", c.in, got, c.want)
+    }
+  }
+}
+
+// END
@@ -0,0 +1,93 @@
+package warc
+
+import (
+  "bufio"
+  "encoding/binary"
+  "fmt"
+  "io"
+  "os"
+)
+
+// Container identifies the outer compressed framing of a .warc.gz or
+// .warc.zst file.
+type Container int
+
+const (
+  ContainerGzip Container = iota
+  ContainerZstd
+)
+
+var (
+  gzipMagic = [2]byte{0x1f, 0x8b}
+  zstdMagic = [4]byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// detectContainer peeks at the leading bytes of br to identify which
+// compression container the stream uses, without consuming them.
+func detectContainer(br *bufio.Reader) (Container, error) {
+  head, err := br.Peek(4)
+  if err != nil && err != io.EOF {
+    return 0, err
+  }
+  switch {
+  case len(head) >= 2 && head[0] == gzipMagic[0] && head[1] == gzipMagic[1]:
+    return ContainerGzip, nil
+  case len(head) == 4 && head[0] == zstdMagic[0] && head[1] == zstdMagic[1] && head[2] == zstdMagic[2] && head[3] == zstdMagic[3]:
+    return ContainerZstd, nil
+  case len(head) == 4 && isSkippableFrameMagic(binary.LittleEndian.Uint32(head)):
+    // A zstd file may open with a skippable frame carrying its dictionary.
+    return ContainerZstd, nil
+  default:
+    return 0, fmt.Errorf("warc: unrecognized container magic %x", head)
+  }
+}
+
+// isSkippableFrameMagic reports whether magic is one of zstd's 16
+// skippable-frame magic numbers, 0x184D2A50 through 0x184D2A5F.
+func isSkippableFrameMagic(magic uint32) bool {
+  return magic&0xfffffff0 == 0x184d2a50
+}
+
+// readZstdDict consumes a leading zstd skippable frame carrying a
+// per-file dictionary, if one is present, and returns its payload so it
+// can be registered via zstd.WithDecoderDicts. If the next frame is an
+// ordinary zstd frame instead, br is left untouched and a nil dictionary
+// is returned.
+func readZstdDict(br *bufio.Reader) ([]byte, error) {
+  head, err := br.Peek(8)
+  if err != nil {
+    if err == io.EOF {
+      return nil, nil
+    }
+    return nil, err
+  }
+  magic := binary.LittleEndian.Uint32(head[0:4])
+  if !isSkippableFrameMagic(magic) {
+    return nil, nil
+  }
+  size := binary.LittleEndian.Uint32(head[4:8])
+  if _, err := br.Discard(8); err != nil {
+    return nil, err
+  }
+  dict := make([]byte, size)
+  if _, err := io.ReadFull(br, dict); err != nil {
+    return nil, err
+  }
+  return dict, nil
+}
+
+// zstdFileDict peeks the leading skippable frame of a zstd-framed WARC
+// file, independent of any particular record's offset, so random-access
+// reads via RecordAt can register the same per-file dictionary that
+// sequential NewReader reads pick up automatically. It returns nil, nil
+// if file has no such frame.
+func zstdFileDict(file string) ([]byte, error) {
+  f, err := os.Open(file)
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+  return readZstdDict(bufio.NewReader(f))
+}
+
+// END
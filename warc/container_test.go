@@ -0,0 +1,180 @@
+package warc
+
+import (
+  "bufio"
+  "bytes"
+  "encoding/binary"
+  "fmt"
+  "os"
+  "path/filepath"
+  "testing"
+
+  "github.com/klauspost/compress/dict"
+  "github.com/klauspost/compress/zstd"
+)
+
+func TestDetectContainer(t *testing.T) {
+  cases := []struct {
+    name string
+    data []byte
+    want Container
+  }{
+    {"gzip", []byte{0x1f, 0x8b, 0x08, 0x00}, ContainerGzip},
+    {"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}, ContainerZstd},
+  }
+  for _, c := range cases {
+    t.Run(c.name, func(t *testing.T) {
+      got, err := detectContainer(bufio.NewReader(bytes.NewReader(c.data)))
+      if err != nil {
+        t.Fatalf("detectContainer: %v", err)
+      }
+      if got != c.want {
+        t.Errorf("detectContainer() = %v, want %v", got, c.want)
+      }
+    })
+  }
+}
+
+func TestDetectContainerUnrecognized(t *testing.T) {
+  _, err := detectContainer(bufio.NewReader(bytes.NewReader([]byte("plain text"))))
+  if err == nil {
+    t.Fatal("detectContainer: expected error for unrecognized magic, got nil")
+  }
+}
+
+func TestIsSkippableFrameMagic(t *testing.T) {
+  if !isSkippableFrameMagic(0x184D2A50) {
+    t.Error("isSkippableFrameMagic(0x184D2A50) = false, want true")
+  }
+  if !isSkippableFrameMagic(0x184D2A5F) {
+    t.Error("isSkippableFrameMagic(0x184D2A5F) = false, want true")
+  }
+  if isSkippableFrameMagic(0x184D2A60) {
+    t.Error("isSkippableFrameMagic(0x184D2A60) = true, want false")
+  }
+  if isSkippableFrameMagic(0x28b52ffd) {
+    t.Error("isSkippableFrameMagic(zstd frame magic) = true, want false")
+  }
+}
+
+func TestReadZstdDictNoFrame(t *testing.T) {
+  br := bufio.NewReader(bytes.NewReader([]byte{0x28, 0xb5, 0x2f, 0xfd, 0, 0, 0, 0}))
+  dict, err := readZstdDict(br)
+  if err != nil {
+    t.Fatalf("readZstdDict: %v", err)
+  }
+  if dict != nil {
+    t.Errorf("readZstdDict() = %v, want nil", dict)
+  }
+  // The ordinary frame bytes must be left unconsumed for the caller.
+  head, err := br.Peek(4)
+  if err != nil || !bytes.Equal(head, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+    t.Errorf("readZstdDict consumed bytes it shouldn't have: Peek(4) = %x, %v", head, err)
+  }
+}
+
+func TestReadZstdDictPresent(t *testing.T) {
+  dictPayload := []byte("dictionary-bytes")
+  var buf bytes.Buffer
+  buf.Write([]byte{0x50, 0x2a, 0x4d, 0x18}) // skippable frame magic 0x184D2A50, little-endian
+  size := []byte{byte(len(dictPayload)), 0, 0, 0}
+  buf.Write(size)
+  buf.Write(dictPayload)
+  buf.Write([]byte{0x28, 0xb5, 0x2f, 0xfd}) // the actual zstd frame follows
+
+  br := bufio.NewReader(&buf)
+  dict, err := readZstdDict(br)
+  if err != nil {
+    t.Fatalf("readZstdDict: %v", err)
+  }
+  if !bytes.Equal(dict, dictPayload) {
+    t.Errorf("readZstdDict() = %q, want %q", dict, dictPayload)
+  }
+  head, err := br.Peek(4)
+  if err != nil || !bytes.Equal(head, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+    t.Errorf("readZstdDict left frame bytes unconsumed incorrectly: Peek(4) = %x, %v", head, err)
+  }
+}
+
+// zstdSkippableFrame wraps dict in the skippable-frame encoding readZstdDict
+// expects, so a test file can carry a per-file dictionary.
+func zstdSkippableFrame(dict []byte) []byte {
+  var buf bytes.Buffer
+  var magic [4]byte
+  binary.LittleEndian.PutUint32(magic[:], 0x184d2a50)
+  buf.Write(magic[:])
+  var size [4]byte
+  binary.LittleEndian.PutUint32(size[:], uint32(len(dict)))
+  buf.Write(size[:])
+  buf.Write(dict)
+  return buf.Bytes()
+}
+
+func TestOpenAndNextRecordRoundTripZstd(t *testing.T) {
+  raw := rawRecord("<urn:uuid:1>", "a zstd-compressed record body")
+
+  enc, err := zstd.NewWriter(nil)
+  if err != nil {
+    t.Fatalf("zstd.NewWriter: %v", err)
+  }
+  frame := enc.EncodeAll([]byte(raw), nil)
+  enc.Close()
+
+  path := filepath.Join(t.TempDir(), "test.warc.zst")
+  if err := os.WriteFile(path, frame, 0o644); err != nil {
+    t.Fatalf("os.WriteFile: %v", err)
+  }
+
+  r, err := Open(path)
+  if err != nil {
+    t.Fatalf("Open: %v", err)
+  }
+  defer r.Close()
+
+  rec, err := r.NextRecord()
+  if err != nil {
+    t.Fatalf("NextRecord: %v", err)
+  }
+  if rec.ID != "<urn:uuid:1>" || string(rec.Data) != "a zstd-compressed record body" {
+    t.Errorf("NextRecord = %+v, want ID <urn:uuid:1> and Data %q", rec, "a zstd-compressed record body")
+  }
+}
+
+func TestRecordAtZstdWithPerFileDictionary(t *testing.T) {
+  samples := make([][]byte, 200)
+  for i := range samples {
+    samples[i] = []byte(rawRecord(
+      fmt.Sprintf("<urn:uuid:sample-%d>", i),
+      fmt.Sprintf("a record compressed against the per-file dictionary, sample %d", i)))
+  }
+  dictBytes, err := dict.BuildZstdDict(samples, dict.Options{MaxDictSize: 1 << 12, HashBytes: 6})
+  if err != nil {
+    t.Fatalf("dict.BuildZstdDict: %v", err)
+  }
+  raw := rawRecord("<urn:uuid:1>", "a record compressed against the per-file dictionary")
+
+  enc, err := zstd.NewWriter(nil, zstd.WithEncoderDict(dictBytes))
+  if err != nil {
+    t.Fatalf("zstd.NewWriter: %v", err)
+  }
+  frame := enc.EncodeAll([]byte(raw), nil)
+  enc.Close()
+
+  skippable := zstdSkippableFrame(dictBytes)
+  contents := append(skippable, frame...)
+
+  path := filepath.Join(t.TempDir(), "test.warc.zst")
+  if err := os.WriteFile(path, contents, 0o644); err != nil {
+    t.Fatalf("os.WriteFile: %v", err)
+  }
+
+  rec, err := RecordAt(path, int64(len(skippable)), int64(len(frame)), OnMismatchFail)
+  if err != nil {
+    t.Fatalf("RecordAt: %v", err)
+  }
+  if rec.ID != "<urn:uuid:1>" || string(rec.Data) != "a record compressed against the per-file dictionary" {
+    t.Errorf("RecordAt = %+v, want ID <urn:uuid:1> and Data %q", rec, "a record compressed against the per-file dictionary")
+  }
+}
+
+// END
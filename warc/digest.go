@@ -0,0 +1,192 @@
+package warc
+
+import (
+  "bytes"
+  "crypto/sha1"
+  "crypto/sha256"
+  "encoding/base32"
+  "fmt"
+  "hash"
+  "log"
+  "strings"
+)
+
+// DigestPolicy controls how ReadRecord reacts when a record's computed
+// digest doesn't match the one declared in its WARC-Block-Digest or
+// WARC-Payload-Digest header.
+type DigestPolicy int
+
+const (
+  // OnMismatchSkip returns the record unchanged, ignoring the mismatch.
+  OnMismatchSkip DigestPolicy = iota
+  // OnMismatchFail returns a *DigestMismatchError instead of the record.
+  OnMismatchFail
+  // OnMismatchWarn logs the mismatch and returns the record anyway.
+  OnMismatchWarn
+)
+
+// Digest is a parsed WARC-Block-Digest/WARC-Payload-Digest header value of
+// the form "algorithm:base32value", e.g.
+// "sha1:3I42H3S6NNFQ2MSVX7XZKYAYSCX5QBYJ".
+type Digest struct {
+  Algorithm string
+  Value     []byte
+}
+
+var digestEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ParseDigest parses a WARC digest header value. Both sha1 and sha256 are
+// recognized.
+func ParseDigest(s string) (Digest, error) {
+  algo, encoded, ok := strings.Cut(s, ":")
+  if !ok {
+    return Digest{}, fmt.Errorf("warc: malformed digest %q", s)
+  }
+  algo = strings.ToLower(algo)
+  switch algo {
+  case "sha1", "sha256":
+  default:
+    return Digest{}, fmt.Errorf("warc: unsupported digest algorithm %q", algo)
+  }
+  value, err := digestEncoding.DecodeString(strings.ToUpper(encoded))
+  if err != nil {
+    return Digest{}, fmt.Errorf("warc: malformed digest %q: %v", s, err)
+  }
+  return Digest{Algorithm: algo, Value: value}, nil
+}
+
+// newHash returns a fresh hash.Hash appropriate for d.Algorithm.
+func (d Digest) newHash() hash.Hash {
+  if d.Algorithm == "sha256" {
+    return sha256.New()
+  }
+  return sha1.New()
+}
+
+// check compares sum, the digest actually computed for recordID, against
+// d, returning a *DigestMismatchError (formatted against the original
+// header value expected) if they disagree, or nil if they match.
+func (d *Digest) check(recordID, expected string, sum []byte) *DigestMismatchError {
+  if string(sum) == string(d.Value) {
+    return nil
+  }
+  return &DigestMismatchError{
+    RecordID: recordID,
+    Expected: expected,
+    Actual:   fmt.Sprintf("%s:%s", d.Algorithm, digestEncoding.EncodeToString(sum)),
+  }
+}
+
+// DigestMismatchError reports that a record's computed digest did not
+// match the one declared in its WARC-Block-Digest or WARC-Payload-Digest
+// header.
+type DigestMismatchError struct {
+  RecordID string
+  Expected string
+  Actual   string
+}
+
+func (e *DigestMismatchError) Error() string {
+  return fmt.Sprintf("warc: digest mismatch for record %s: expected %s, got %s", e.RecordID, e.Expected, e.Actual)
+}
+
+// maxHeaderBuffer bounds how much of a record's block payloadWriter will
+// buffer while looking for the HTTP header/body separator, so a record
+// with no such separator (and so no bounded header section) doesn't grow
+// the buffer without limit.
+const maxHeaderBuffer = 64 * 1024
+
+// payloadWriter hashes only the portion of a record's block that follows
+// the blank line separating an HTTP status/header block from its body,
+// so WARC-Payload-Digest can be verified incrementally in the same read
+// pass used for WARC-Block-Digest. Bytes before the separator is found
+// are buffered (bounded by maxHeaderBuffer). If no separator ever turns
+// up, either because the cap is hit or because the whole block has been
+// written with none found, the buffered bytes are hashed as the payload
+// in one go, mirroring the WARC convention for "resource"/"metadata"
+// record types, whose payload digest covers the entire block.
+type payloadWriter struct {
+  hasher  hash.Hash
+  buf     []byte
+  started bool
+}
+
+func newPayloadWriter(h hash.Hash) *payloadWriter {
+  return &payloadWriter{hasher: h}
+}
+
+func (w *payloadWriter) Write(p []byte) (int, error) {
+  n := len(p)
+  if w.started {
+    w.hasher.Write(p)
+    return n, nil
+  }
+
+  w.buf = append(w.buf, p...)
+  if i := httpBodyStart(w.buf); i >= 0 {
+    w.hasher.Write(w.buf[i:])
+    w.started = true
+    w.buf = nil
+  } else if len(w.buf) > maxHeaderBuffer {
+    w.hasher.Write(w.buf)
+    w.started = true
+    w.buf = nil
+  }
+  return n, nil
+}
+
+// Finalize must be called once the entire block has been written. If no
+// header/body boundary ever turned up, the buffered bytes (the whole
+// block) are hashed now as the payload.
+func (w *payloadWriter) Finalize() {
+  if w.started {
+    return
+  }
+  w.hasher.Write(w.buf)
+  w.started = true
+  w.buf = nil
+}
+
+// handleMismatch applies policy to mismatch, which may be nil if the
+// digest matched. It returns a non-nil error only under OnMismatchFail.
+func handleMismatch(mismatch *DigestMismatchError, policy DigestPolicy) error {
+  if mismatch == nil {
+    return nil
+  }
+  switch policy {
+  case OnMismatchFail:
+    return mismatch
+  case OnMismatchWarn:
+    log.Print(mismatch)
+  case OnMismatchSkip:
+    // Record is returned as-is despite the mismatch.
+  }
+  return nil
+}
+
+// httpBodyStart returns the offset just past the blank line separating
+// an HTTP status/header block from its body, or -1 if none has been seen
+// yet.
+func httpBodyStart(b []byte) int {
+  if i := bytes.Index(b, []byte("\r\n\r\n")); i >= 0 {
+    return i + 4
+  }
+  if i := bytes.Index(b, []byte("\n\n")); i >= 0 {
+    return i + 2
+  }
+  return -1
+}
+
+// HTTPBody strips the HTTP status line and headers from data, a WARC
+// response record's full block content, returning just the entity body
+// that follows the blank line separating them, using the same boundary
+// ReadRecord uses to verify WARC-Payload-Digest. If no such boundary is
+// found, data is returned unchanged.
+func HTTPBody(data []byte) []byte {
+  if i := httpBodyStart(data); i >= 0 {
+    return data[i:]
+  }
+  return data
+}
+
+// END
@@ -0,0 +1,171 @@
+package warc
+
+import (
+  "crypto/sha1"
+  "testing"
+)
+
+func sha1Digest(t *testing.T, s string) string {
+  t.Helper()
+  sum := sha1.Sum([]byte(s))
+  return "sha1:" + digestEncoding.EncodeToString(sum[:])
+}
+
+func TestParseDigest(t *testing.T) {
+  d, err := ParseDigest("sha1:3I42H3S6NNFQ2MSVX7XZKYAYSCX5QBY")
+  if err != nil {
+    t.Fatalf("ParseDigest: %v", err)
+  }
+  if d.Algorithm != "sha1" {
+    t.Errorf("Algorithm = %q, want sha1", d.Algorithm)
+  }
+
+  if _, err := ParseDigest("nocolon"); err == nil {
+    t.Error("ParseDigest(malformed): expected error, got nil")
+  }
+  if _, err := ParseDigest("md5:abcd"); err == nil {
+    t.Error("ParseDigest(unsupported algorithm): expected error, got nil")
+  }
+}
+
+func TestDigestCheck(t *testing.T) {
+  header := sha1Digest(t, "hello")
+  d, err := ParseDigest(header)
+  if err != nil {
+    t.Fatalf("ParseDigest: %v", err)
+  }
+
+  hasher := d.newHash()
+  hasher.Write([]byte("hello"))
+  if mismatch := d.check("rec-1", header, hasher.Sum(nil)); mismatch != nil {
+    t.Errorf("check() on matching content = %v, want nil", mismatch)
+  }
+
+  hasher = d.newHash()
+  hasher.Write([]byte("goodbye"))
+  mismatch := d.check("rec-1", header, hasher.Sum(nil))
+  if mismatch == nil {
+    t.Fatal("check() on mismatched content = nil, want *DigestMismatchError")
+  }
+  if mismatch.RecordID != "rec-1" || mismatch.Expected != header {
+    t.Errorf("mismatch = %+v, want RecordID=rec-1 Expected=%s", mismatch, header)
+  }
+}
+
+func TestHandleMismatch(t *testing.T) {
+  if err := handleMismatch(nil, OnMismatchFail); err != nil {
+    t.Errorf("handleMismatch(nil, ...) = %v, want nil", err)
+  }
+
+  mismatch := &DigestMismatchError{RecordID: "r", Expected: "a", Actual: "b"}
+  if err := handleMismatch(mismatch, OnMismatchFail); err != mismatch {
+    t.Errorf("handleMismatch(_, OnMismatchFail) = %v, want %v", err, mismatch)
+  }
+  if err := handleMismatch(mismatch, OnMismatchWarn); err != nil {
+    t.Errorf("handleMismatch(_, OnMismatchWarn) = %v, want nil", err)
+  }
+  if err := handleMismatch(mismatch, OnMismatchSkip); err != nil {
+    t.Errorf("handleMismatch(_, OnMismatchSkip) = %v, want nil", err)
+  }
+}
+
+func TestHTTPBodyStart(t *testing.T) {
+  cases := []struct {
+    name string
+    in   string
+    want int
+  }{
+    {"crlf", "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\nbody", 45},
+    {"lf", "HTTP/1.1 200 OK\nContent-Type: text/plain\n\nbody", 42},
+    {"none", "no header boundary here", -1},
+  }
+  for _, c := range cases {
+    t.Run(c.name, func(t *testing.T) {
+      if got := httpBodyStart([]byte(c.in)); got != c.want {
+        t.Errorf("httpBodyStart(%q) = %d, want %d", c.in, got, c.want)
+      }
+    })
+  }
+}
+
+func TestHTTPBody(t *testing.T) {
+  cases := []struct {
+    name string
+    in   string
+    want string
+  }{
+    {"crlf", "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\nbody", "body"},
+    {"lf", "HTTP/1.1 200 OK\nContent-Type: text/plain\n\nbody", "body"},
+    {"none", "no header boundary here", "no header boundary here"},
+  }
+  for _, c := range cases {
+    t.Run(c.name, func(t *testing.T) {
+      if got := string(HTTPBody([]byte(c.in))); got != c.want {
+        t.Errorf("HTTPBody(%q) = %q, want %q", c.in, got, c.want)
+      }
+    })
+  }
+}
+
+func TestPayloadWriterHashesOnlyBody(t *testing.T) {
+  header := "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\n"
+  body := "hello"
+
+  want := sha1.Sum([]byte(body))
+  pw := newPayloadWriter(sha1.New())
+  if _, err := pw.Write([]byte(header)); err != nil {
+    t.Fatalf("Write(header): %v", err)
+  }
+  if _, err := pw.Write([]byte(body)); err != nil {
+    t.Fatalf("Write(body): %v", err)
+  }
+  if !pw.started {
+    t.Fatal("payloadWriter never found the header/body boundary")
+  }
+  if got := pw.hasher.Sum(nil); string(got) != string(want[:]) {
+    t.Errorf("payloadWriter hashed %x, want %x (body only)", got, want)
+  }
+}
+
+func TestPayloadWriterNoBoundaryBeforeFinalize(t *testing.T) {
+  pw := newPayloadWriter(sha1.New())
+  if _, err := pw.Write([]byte("no boundary in this block at all")); err != nil {
+    t.Fatalf("Write: %v", err)
+  }
+  if pw.started {
+    t.Error("payloadWriter.started = true before Finalize for a block with no header/body boundary")
+  }
+}
+
+func TestPayloadWriterFinalizeHashesWholeBlockWhenNoBoundary(t *testing.T) {
+  block := "no boundary in this block at all"
+  want := sha1.Sum([]byte(block))
+
+  pw := newPayloadWriter(sha1.New())
+  if _, err := pw.Write([]byte(block)); err != nil {
+    t.Fatalf("Write: %v", err)
+  }
+  pw.Finalize()
+  if !pw.started {
+    t.Fatal("payloadWriter.started = false after Finalize")
+  }
+  if got := pw.hasher.Sum(nil); string(got) != string(want[:]) {
+    t.Errorf("Finalize hashed %x, want %x (whole block)", got, want)
+  }
+}
+
+func TestPayloadWriterFinalizeNoopAfterBoundaryFound(t *testing.T) {
+  header := "HTTP/1.1 200 OK\r\n\r\n"
+  body := "hello"
+  want := sha1.Sum([]byte(body))
+
+  pw := newPayloadWriter(sha1.New())
+  pw.Write([]byte(header))
+  pw.Write([]byte(body))
+  pw.Finalize()
+  if got := pw.hasher.Sum(nil); string(got) != string(want[:]) {
+    t.Errorf("Finalize after boundary found hashed %x, want %x (body only)", got, want)
+  }
+}
+
+// END
@@ -0,0 +1,310 @@
+// Package warc reads WARC records from the per-record framed layouts used
+// by Common Crawl: each record is its own self-contained gzip member or
+// zstd frame, so a byte offset into a .warc.gz/.warc.zst file (as recorded
+// in a WAT or CDX index) is enough to decode exactly that record without
+// touching anything else in the file.
+package warc
+
+import (
+  "bufio"
+  "compress/gzip"
+  "fmt"
+  "hash"
+  "io"
+  "os"
+  "strconv"
+  "strings"
+
+  "github.com/klauspost/compress/zstd"
+)
+
+// Record holds the parsed header fields and payload of a single WARC
+// record.
+type Record struct {
+  Length        int
+  ID            string
+  RefersTo      string
+  Type          string
+  BlockDigest   string
+  PayloadDigest string
+  Header        []string
+  Data          []byte
+}
+
+// Reader reads successive WARC records out of an underlying gzip
+// multistream or zstd frame sequence, one record per member/frame.
+type Reader struct {
+  gz     *gzip.Reader
+  zdec   *zstd.Decoder
+  br     *bufio.Reader
+  closer io.Closer
+
+  // OnDigestMismatch controls how NextRecord reacts when a record's
+  // WARC-Block-Digest or WARC-Payload-Digest header doesn't match its
+  // actual content. The zero
+  // value, OnMismatchSkip, returns the record unverified.
+  OnDigestMismatch DigestPolicy
+}
+
+// Open opens the WARC file at path for sequential reading via NextRecord,
+// autodetecting whether it is gzip- or zstd-framed.
+func Open(path string) (*Reader, error) {
+  f, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  r, err := NewReader(f)
+  if err != nil {
+    f.Close()
+    return nil, err
+  }
+  r.closer = f
+  return r, nil
+}
+
+// NewReader wraps r, which must contain one or more gzip members or zstd
+// frames each holding a single WARC record, for sequential reading via
+// NextRecord. The container is identified by its magic bytes; for zstd, a
+// leading skippable frame carrying a per-file dictionary is honored if
+// present.
+func NewReader(r io.Reader) (*Reader, error) {
+  br := bufio.NewReader(r)
+  c, err := detectContainer(br)
+  if err != nil {
+    return nil, err
+  }
+  switch c {
+  case ContainerGzip:
+    gz, err := gzip.NewReader(br)
+    if err != nil {
+      return nil, err
+    }
+    gz.Multistream(true)
+    return &Reader{gz: gz, br: bufio.NewReader(gz)}, nil
+  case ContainerZstd:
+    dict, err := readZstdDict(br)
+    if err != nil {
+      return nil, err
+    }
+    var opts []zstd.DOption
+    if dict != nil {
+      opts = append(opts, zstd.WithDecoderDicts(dict))
+    }
+    zdec, err := zstd.NewReader(br, opts...)
+    if err != nil {
+      return nil, err
+    }
+    return &Reader{zdec: zdec, br: bufio.NewReader(zdec)}, nil
+  default:
+    return nil, fmt.Errorf("warc: unrecognized container")
+  }
+}
+
+// NextRecord reads and returns the next record in the stream, returning
+// io.EOF once the stream is exhausted.
+func (r *Reader) NextRecord() (*Record, error) {
+  return ReadRecord(r.br, r.OnDigestMismatch)
+}
+
+// Close releases the resources held by the reader, including the
+// underlying file if the Reader was obtained from Open.
+func (r *Reader) Close() error {
+  var err error
+  if r.gz != nil {
+    err = r.gz.Close()
+  }
+  if r.zdec != nil {
+    r.zdec.Close()
+  }
+  if r.closer != nil {
+    if cerr := r.closer.Close(); err == nil {
+      err = cerr
+    }
+  }
+  return err
+}
+
+// RecordAt opens file at offset and decodes exactly one gzip member or
+// zstd frame of compressed length length, returning the single WARC
+// record it contains. This is the random-access counterpart to
+// NextRecord, suited to looking up records by the offset and length
+// recorded in a WAT or CDX index: for gzip that length is
+// Gzip-Metadata/Deflate-Length, for zstd it is the frame's on-disk size.
+// policy governs how a WARC-Block-Digest or WARC-Payload-Digest mismatch
+// is handled, which
+// matters here more than in NextRecord since a corrupt index offset would
+// otherwise silently decode garbage.
+func RecordAt(file string, offset, length int64, policy DigestPolicy) (*Record, error) {
+  f, err := os.Open(file)
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+
+  if _, err := f.Seek(offset, io.SeekStart); err != nil {
+    return nil, err
+  }
+
+  br := bufio.NewReader(io.LimitReader(f, length))
+  c, err := detectContainer(br)
+  if err != nil {
+    return nil, err
+  }
+  switch c {
+  case ContainerGzip:
+    gz, err := gzip.NewReader(br)
+    if err != nil {
+      return nil, err
+    }
+    defer gz.Close()
+    return ReadRecord(bufio.NewReader(gz), policy)
+  case ContainerZstd:
+    dict, err := zstdFileDict(file)
+    if err != nil {
+      return nil, err
+    }
+    var opts []zstd.DOption
+    if dict != nil {
+      opts = append(opts, zstd.WithDecoderDicts(dict))
+    }
+    zdec, err := zstd.NewReader(br, opts...)
+    if err != nil {
+      return nil, err
+    }
+    defer zdec.Close()
+    return ReadRecord(bufio.NewReader(zdec), policy)
+  default:
+    return nil, fmt.Errorf("warc: unrecognized container")
+  }
+}
+
+// ReadRecord reads a single WARC record (header block plus payload) from
+// br, returning io.EOF once there is nothing left to read. It makes no
+// assumption about framing below br, so it works equally well against a
+// plain decompressed WAT/WARC stream or against a single gzip member
+// opened by RecordAt. If the record declares a WARC-Block-Digest and/or a
+// WARC-Payload-Digest, the corresponding hash(es) are computed
+// incrementally as the payload is read and checked against policy. For an
+// HTTP response/request record, the payload digest covers only the
+// entity body, i.e. whatever follows the blank line ending the HTTP
+// status/header block within rec.Data; for any other record type, with
+// no such boundary, the whole block is the payload, per the WARC spec.
+func ReadRecord(br *bufio.Reader, policy DigestPolicy) (*Record, error) {
+  rec := Record{}
+  var line string
+  var err error
+
+  /* We should always enter this function at the beginning of a record
+     (possibly skipping blank lines left over from the previous one). */
+  for {
+    line, err = br.ReadString('\n')
+    if err != nil {
+      return nil, err
+    }
+    line = strings.TrimSpace(line)
+    if line == "WARC/1.0" {
+      break
+    } else if line == "" {
+      continue
+    } else {
+      return nil, fmt.Errorf("warc: malformed first line %q", line)
+    }
+  }
+  rec.Header = append(rec.Header, line)
+
+  // Get the rest of the header.
+  for {
+    line, err = br.ReadString('\n')
+    if err != nil {
+      if err == io.EOF {
+        break
+      }
+      return nil, err
+    }
+    line = strings.TrimSpace(line)
+    if line == "" {
+      break
+    }
+    if strings.HasPrefix(line, "WARC-Type: ") {
+      rec.Type = line[11:]
+    } else if strings.HasPrefix(line, "Content-Length: ") {
+      rec.Length, err = strconv.Atoi(line[16:])
+      if err != nil {
+        return nil, err
+      }
+    } else if strings.HasPrefix(line, "WARC-Record-ID: ") {
+      rec.ID = line[16:]
+    } else if strings.HasPrefix(line, "WARC-Refers-To: ") {
+      rec.RefersTo = line[16:]
+    } else if strings.HasPrefix(line, "WARC-Block-Digest: ") {
+      rec.BlockDigest = line[19:]
+    } else if strings.HasPrefix(line, "WARC-Payload-Digest: ") {
+      rec.PayloadDigest = line[21:]
+    }
+    rec.Header = append(rec.Header, line)
+  }
+
+  if rec.Length == 0 {
+    return nil, fmt.Errorf("warc: record missing Content-Length")
+  }
+  rec.Data = make([]byte, rec.Length)
+
+  var blockDigest *Digest
+  var blockHasher hash.Hash
+  if rec.BlockDigest != "" {
+    d, err := ParseDigest(rec.BlockDigest)
+    if err != nil {
+      return nil, err
+    }
+    blockDigest = &d
+    blockHasher = d.newHash()
+  }
+
+  var payloadDigest *Digest
+  var payload *payloadWriter
+  if rec.PayloadDigest != "" {
+    d, err := ParseDigest(rec.PayloadDigest)
+    if err != nil {
+      return nil, err
+    }
+    payloadDigest = &d
+    payload = newPayloadWriter(d.newHash())
+    if rec.Type != "response" && rec.Type != "request" {
+      // Only HTTP records split into headers and entity body; for any
+      // other type the whole block is the payload.
+      payload.started = true
+    }
+  }
+
+  var writers []io.Writer
+  if blockHasher != nil {
+    writers = append(writers, blockHasher)
+  }
+  if payload != nil {
+    writers = append(writers, payload)
+  }
+
+  src := io.Reader(br)
+  if len(writers) > 0 {
+    src = io.TeeReader(br, io.MultiWriter(writers...))
+  }
+  if _, err := io.ReadFull(src, rec.Data); err != nil {
+    return nil, err
+  }
+
+  if blockHasher != nil {
+    if err := handleMismatch(blockDigest.check(rec.ID, rec.BlockDigest, blockHasher.Sum(nil)), policy); err != nil {
+      return nil, err
+    }
+  }
+  if payload != nil {
+    payload.Finalize()
+    if err := handleMismatch(payloadDigest.check(rec.ID, rec.PayloadDigest, payload.hasher.Sum(nil)), policy); err != nil {
+      return nil, err
+    }
+  }
+
+  return &rec, nil
+}
+
+// END
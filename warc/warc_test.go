@@ -0,0 +1,166 @@
+package warc
+
+import (
+  "bufio"
+  "bytes"
+  "compress/gzip"
+  "crypto/sha1"
+  "io"
+  "os"
+  "path/filepath"
+  "strconv"
+  "strings"
+  "testing"
+)
+
+func sha1Header(name, body string) string {
+  sum := sha1.Sum([]byte(body))
+  return name + ": sha1:" + digestEncoding.EncodeToString(sum[:]) + "\r\n"
+}
+
+func TestReadRecordParsesRealDigestHeaders(t *testing.T) {
+  block := "hello world"
+  raw := "WARC/1.0\r\n" +
+    "WARC-Type: resource\r\n" +
+    "WARC-Record-ID: <urn:uuid:1>\r\n" +
+    "Content-Length: " + "11" + "\r\n" +
+    sha1Header("WARC-Block-Digest", block) +
+    sha1Header("WARC-Payload-Digest", block) +
+    "\r\n" +
+    block
+
+  rec, err := ReadRecord(bufio.NewReader(strings.NewReader(raw)), OnMismatchFail)
+  if err != nil {
+    t.Fatalf("ReadRecord: %v", err)
+  }
+  if !strings.HasPrefix(rec.BlockDigest, "sha1:") {
+    t.Errorf("rec.BlockDigest = %q, want it to start with sha1: (header-slice offset regression)", rec.BlockDigest)
+  }
+  if !strings.HasPrefix(rec.PayloadDigest, "sha1:") {
+    t.Errorf("rec.PayloadDigest = %q, want it to start with sha1: (header-slice offset regression)", rec.PayloadDigest)
+  }
+  if string(rec.Data) != block {
+    t.Errorf("rec.Data = %q, want %q", rec.Data, block)
+  }
+}
+
+func TestReadRecordRejectsCorruptBlockDigest(t *testing.T) {
+  block := "hello world"
+  raw := "WARC/1.0\r\n" +
+    "WARC-Type: resource\r\n" +
+    "WARC-Record-ID: <urn:uuid:1>\r\n" +
+    "Content-Length: 11\r\n" +
+    "WARC-Block-Digest: sha1:3I42H3S6NNFQ2MSVX7XZKYAYSCX5QBY\r\n" +
+    "\r\n" +
+    block
+
+  _, err := ReadRecord(bufio.NewReader(strings.NewReader(raw)), OnMismatchFail)
+  if err == nil {
+    t.Fatal("ReadRecord: expected a digest mismatch error, got nil")
+  }
+}
+
+func TestReadRecordVerifiesPayloadDigestOverWholeBlockWhenNoHTTPBoundary(t *testing.T) {
+  // A "resource" record's block has no HTTP header/body boundary, so its
+  // payload digest must cover the whole block, not be silently skipped.
+  block := "plain non-HTTP resource content"
+  raw := "WARC/1.0\r\n" +
+    "WARC-Type: resource\r\n" +
+    "WARC-Record-ID: <urn:uuid:1>\r\n" +
+    "Content-Length: " + "31" + "\r\n" +
+    "WARC-Payload-Digest: sha1:3I42H3S6NNFQ2MSVX7XZKYAYSCX5QBY\r\n" +
+    "\r\n" +
+    block
+
+  _, err := ReadRecord(bufio.NewReader(strings.NewReader(raw)), OnMismatchFail)
+  if err == nil {
+    t.Fatal("ReadRecord: expected a corrupt payload digest to fail verification, got nil")
+  }
+  if _, ok := err.(*DigestMismatchError); !ok {
+    t.Errorf("ReadRecord error = %v (%T), want *DigestMismatchError", err, err)
+  }
+}
+
+// rawRecord builds the on-the-wire text of a minimal "resource" WARC
+// record with the given id and body, in the form ReadRecord expects.
+func rawRecord(id, body string) string {
+  return "WARC/1.0\r\n" +
+    "WARC-Type: resource\r\n" +
+    "WARC-Record-ID: " + id + "\r\n" +
+    "Content-Length: " + strconv.Itoa(len(body)) + "\r\n" +
+    "\r\n" +
+    body
+}
+
+// gzipMember compresses raw as a standalone gzip member, the framing
+// NewReader/RecordAt expect one WARC record per member.
+func gzipMember(t *testing.T, raw string) []byte {
+  t.Helper()
+  var buf bytes.Buffer
+  gz := gzip.NewWriter(&buf)
+  if _, err := gz.Write([]byte(raw)); err != nil {
+    t.Fatalf("gzip.Write: %v", err)
+  }
+  if err := gz.Close(); err != nil {
+    t.Fatalf("gzip.Close: %v", err)
+  }
+  return buf.Bytes()
+}
+
+func TestOpenAndNextRecordRoundTripGzipMultistream(t *testing.T) {
+  rec1 := gzipMember(t, rawRecord("<urn:uuid:1>", "first record body"))
+  rec2 := gzipMember(t, rawRecord("<urn:uuid:2>", "second record body"))
+
+  path := filepath.Join(t.TempDir(), "test.warc.gz")
+  if err := os.WriteFile(path, append(rec1, rec2...), 0o644); err != nil {
+    t.Fatalf("os.WriteFile: %v", err)
+  }
+
+  r, err := Open(path)
+  if err != nil {
+    t.Fatalf("Open: %v", err)
+  }
+  defer r.Close()
+
+  got1, err := r.NextRecord()
+  if err != nil {
+    t.Fatalf("NextRecord (1st): %v", err)
+  }
+  if got1.ID != "<urn:uuid:1>" || string(got1.Data) != "first record body" {
+    t.Errorf("NextRecord (1st) = %+v, want ID <urn:uuid:1> and Data %q", got1, "first record body")
+  }
+
+  got2, err := r.NextRecord()
+  if err != nil {
+    t.Fatalf("NextRecord (2nd): %v", err)
+  }
+  if got2.ID != "<urn:uuid:2>" || string(got2.Data) != "second record body" {
+    t.Errorf("NextRecord (2nd) = %+v, want ID <urn:uuid:2> and Data %q", got2, "second record body")
+  }
+
+  if _, err := r.NextRecord(); err != io.EOF {
+    t.Errorf("NextRecord (3rd) error = %v, want io.EOF", err)
+  }
+}
+
+func TestRecordAtGzipMultistreamMember(t *testing.T) {
+  rec1 := gzipMember(t, rawRecord("<urn:uuid:1>", "first record body"))
+  rec2 := gzipMember(t, rawRecord("<urn:uuid:2>", "second record body"))
+
+  path := filepath.Join(t.TempDir(), "test.warc.gz")
+  if err := os.WriteFile(path, append(rec1, rec2...), 0o644); err != nil {
+    t.Fatalf("os.WriteFile: %v", err)
+  }
+
+  // RecordAt should be able to jump straight to the second member using
+  // its offset and compressed length, without decoding the first.
+  rec, err := RecordAt(path, int64(len(rec1)), int64(len(rec2)), OnMismatchFail)
+  if err != nil {
+    t.Fatalf("RecordAt: %v", err)
+  }
+  if rec.ID != "<urn:uuid:2>" || string(rec.Data) != "second record body" {
+    t.Errorf("RecordAt = %+v, want ID <urn:uuid:2> and Data %q", rec, "second record body")
+  }
+}
+
+// END